@@ -17,6 +17,54 @@ type Cli struct {
 	Stderr   io.Writer
 	handlers []Handler
 	Usage    func()
+
+	// PluginPaths lists the directories DiscoverPlugins searches for
+	// "docker-<name>" executables. When nil, DiscoverPlugins falls back
+	// to $PATH plus ~/.docker/cli-plugins.
+	// PluginPaths 列出了DiscoverPlugins查找"docker-<name>"可执行文件的目录
+	// 为nil时，DiscoverPlugins会使用$PATH加上~/.docker/cli-plugins
+	PluginPaths []string
+	plugins     map[string]*Plugin
+
+	middleware []Middleware
+
+	// Formatter, when set, seeds the default OutputFormatter Emit uses
+	// before --output/--format (if present on the invoked command's
+	// FlagSet) has a chance to override it.
+	// Formatter 在被设置时，会作为Emit使用的默认OutputFormatter，
+	// 直到被调用命令的FlagSet上的--output/--format（如果存在）覆盖
+	Formatter OutputFormatter
+
+	// formatter is the OutputFormatter Emit actually renders through for
+	// the command currently being dispatched. Run and CmdHelp reset it to
+	// Formatter (or the text default) at the start of every dispatch, so
+	// an --output/--format flag from one invocation never leaks into the
+	// next.
+	formatter OutputFormatter
+}
+
+// Use appends mw to the middleware chain wrapped around every resolved
+// command before Run invokes it -- including external plugins dispatched
+// through DiscoverPlugins, so a --dry-run interceptor, auth check, or
+// logging middleware applies uniformly regardless of whether a
+// subcommand is a built-in Cmd method or a plugin exec. Middleware
+// registered first runs outermost, so it sees the command's final result
+// (and, via InitError, any Initializer failure) as well as errors raised
+// by middleware registered after it.
+// Use 将mw追加到中间件链中，该链在Run调用每一个已解析的命令之前对其进行包装
+// 先注册的中间件在最外层运行，因此它既能看到命令的最终结果
+// （以及通过InitError表示的Initializer失败），也能看到在它之后注册的
+// 中间件所抛出的错误
+func (cli *Cli) Use(mw ...Middleware) {
+	cli.middleware = append(cli.middleware, mw...)
+}
+
+// chain wraps next with every registered Middleware, outermost first.
+func (cli *Cli) chain(next CommandFunc) CommandFunc {
+	for i := len(cli.middleware) - 1; i >= 0; i-- {
+		next = cli.middleware[i](next)
+	}
+	return next
 }
 
 // Handler holds the different commands Cli will call
@@ -47,59 +95,159 @@ func New(handlers ...Handler) *Cli {
 	return cli
 }
 
-// initErr is an error returned upon initialization of a handler implementing Initializer.
-// initErr是一个返回错误的结构体，在实现Initializer接口的handler初始化时返回对应的错误
-type initErr struct{ error }
+// InitError is the error returned when a handler implementing Initializer
+// fails to initialize. It is a distinct type, rather than the plain error
+// Initialize returned, so middleware wrapping a command can branch on it
+// with a type assertion.
+// InitError 是当一个实现了Initializer接口的handler初始化失败时返回的错误
+// 它是一个独立的类型，而不是Initialize直接返回的error，这样封装一个命令的
+// middleware就可以通过类型断言来对它进行区分处理
+type InitError struct{ error }
 
-func (err initErr) Error() string {
-	return err.Error()
+func (e InitError) Error() string {
+	return e.error.Error()
 }
 
-func (cli *Cli) command(args ...string) (func(...string) error, error) {
-	for _, c := range cli.handlers {
-		if c == nil {
-			continue
-		}
-		camelArgs := make([]string, len(args))
-		for i, s := range args {
+// CommandFunc is the signature every resolved Cmd method (and every
+// Middleware) is invoked as.
+type CommandFunc func(args ...string) error
+
+// Middleware wraps a CommandFunc with cross-cutting behavior -- logging,
+// metrics, panic recovery, authentication, deprecation warnings, a
+// --dry-run interceptor, and so on -- without touching every CmdFoo.
+// Middleware 用横切关注点（日志、指标、panic恢复、鉴权、弃用警告、
+// --dry-run拦截器等等）包装一个CommandFunc，而无需改动每一个CmdFoo
+type Middleware func(next CommandFunc) CommandFunc
+
+// Grouper can be optionally implemented by a Handler that wants to
+// register child handlers under a subcommand prefix, enabling nested
+// subcommands like "docker image ls" without requiring one handler to
+// carry every CmdImageLs-style method itself. SubHandlers is keyed by
+// the first token of the nested command (e.g. "image") and searched
+// recursively by Cli.command.
+// Grouper接口 可以被一个Handler作为可选实现，用于在一个子命令前缀下
+// 注册子级handler，从而支持像"docker image ls"这样的嵌套子命令，
+// 而无需让一个handler自己携带所有CmdImageLs风格的方法。SubHandlers
+// 以嵌套命令的第一个token为键（例如"image"），由Cli.command递归查找
+type Grouper interface {
+	SubHandlers() map[string]Handler
+}
+
+// currentCommandPath holds the full token path ("image ls") of the
+// command currently being resolved/run, so Subcmd can print it instead
+// of just the leaf name. It is only meaningful while Run or CmdHelp are
+// actively dispatching a command.
+var currentCommandPath string
+
+// command resolves the Handler method that best matches a longest
+// prefix of args, camel-casing each segment into a Cmd<Segment> method
+// name (e.g. "image", "ls" -> CmdImageLs) and trying progressively
+// shorter prefixes until one resolves. If no flat match is found, it
+// consults any Grouper among the handlers and recurses into its
+// SubHandlers using the first remaining token, allowing arbitrarily deep
+// nesting. It returns the resolved function, the number of leading args
+// it consumed as the command path, and any error.
+func (cli *Cli) command(args ...string) (CommandFunc, int, error) {
+	return lookupCommand(cli.handlers, args)
+}
+
+func lookupCommand(handlers []Handler, args []string) (CommandFunc, int, error) {
+prefixes:
+	for depth := len(args); depth >= 1; depth-- {
+		camelArgs := make([]string, depth)
+		for i, s := range args[:depth] {
 			if len(s) == 0 {
-				return nil, errors.New("empty command")
+				// An empty token can't be part of a method name; try a
+				// shorter prefix that stops before it.
+				continue prefixes
 			}
 			camelArgs[i] = strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
 		}
 		methodName := "Cmd" + strings.Join(camelArgs, "")
-		method := reflect.ValueOf(c).MethodByName(methodName)
-		if method.IsValid() {
-			if c, ok := c.(Initializer); ok {
-				if err := c.Initialize(); err != nil {
-					return nil, initErr{err}
-				}
+		for _, h := range handlers {
+			if h == nil {
+				continue
+			}
+			method := reflect.ValueOf(h).MethodByName(methodName)
+			if !method.IsValid() {
+				continue
 			}
-			return method.Interface().(func(...string) error), nil
+			cmd := method.Interface().(func(...string) error)
+			return withInitializer(h, cmd), depth, nil
 		}
 	}
-	return nil, errors.New("command not found")
+
+	if len(args) == 0 {
+		return nil, 0, errors.New("command not found")
+	}
+	for _, h := range handlers {
+		g, ok := h.(Grouper)
+		if !ok {
+			continue
+		}
+		sub, ok := g.SubHandlers()[args[0]]
+		if !ok {
+			continue
+		}
+		fn, depth, err := lookupCommand([]Handler{sub}, args[1:])
+		if err != nil {
+			continue
+		}
+		return fn, depth + 1, nil
+	}
+	return nil, 0, errors.New("command not found")
+}
+
+// withInitializer wraps cmd so that, when invoked, it first runs h's
+// Initialize method (if h implements Initializer), surfacing any failure
+// as an InitError. Running this inside the returned CommandFunc -- rather
+// than eagerly during resolution -- lets it pass through the middleware
+// chain like any other command error.
+func withInitializer(h Handler, cmd func(...string) error) CommandFunc {
+	return func(args ...string) error {
+		if init, ok := h.(Initializer); ok {
+			if err := init.Initialize(); err != nil {
+				return InitError{err}
+			}
+		}
+		return cmd(args...)
+	}
+}
+
+// beginDispatch resets per-invocation dispatch state (the command path
+// Subcmd prints and the OutputFormatter Emit renders through) for cli and
+// returns a func that clears the command path and the dispatching Cli
+// again, to be deferred by the caller. This
+// must run at the start of every Run/CmdHelp call -- including ones that
+// dispatch to a plugin -- so state from one invocation, or from another
+// *Cli, never leaks into the next.
+func (cli *Cli) beginDispatch(path string) func() {
+	currentCommandPath = path
+	dispatchingCli = cli
+	if cli.Formatter != nil {
+		cli.formatter = cli.Formatter
+	} else {
+		cli.formatter = textFormatter{}
+	}
+	return func() {
+		currentCommandPath = ""
+		dispatchingCli = nil
+	}
 }
 
 // Run executes the specified command.
 // Run方法执行指定的命令
 func (cli *Cli) Run(args ...string) error {
-	if len(args) > 1 {
-		command, err := cli.command(args[:2]...)
-		switch err := err.(type) {
-		case nil:
-			return command(args[2:]...)
-		case initErr:
-			return err.error
-		}
-	}
 	if len(args) > 0 {
-		command, err := cli.command(args[0])
-		switch err := err.(type) {
-		case nil:
-			return command(args[1:]...)
-		case initErr:
-			return err.error
+		command, depth, err := cli.command(args...)
+		if err == nil {
+			defer cli.beginDispatch(strings.Join(args[:depth], " "))()
+			return cli.chain(command)(args[depth:]...)
+		}
+		if _, ok := cli.plugins[args[0]]; ok {
+			defer cli.beginDispatch(args[0])()
+			plugin := args[0]
+			return cli.chain(func(a ...string) error { return cli.runPlugin(plugin, a...) })(args[1:]...)
 		}
 		cli.noSuchCommand(args[0])
 	}
@@ -119,31 +267,24 @@ func (cli *Cli) noSuchCommand(command string) {
 // If more than one command is specified, information is only shown for the first command.
 //
 // Usage: docker help COMMAND or docker COMMAND --help
+//
+// Every subcommand also accepts --output/--format to select text, json,
+// yaml, or a custom Go template as its output (see Emit).
 // CmdHelp 在Docker命令行上显示提示信息
 //
 // 如果指定了多个命令，只显示第一个命令的提示信息
 //
 // 用法：docker help COMMAND 或者 docker COMMAND --help
 //
+// 每一个子命令也都接受--output/--format标记，用来选择text、json、yaml
+// 或者一个自定义的Go模板作为输出格式（参见Emit）
+//
 func (cli *Cli) CmdHelp(args ...string) error {
-	if len(args) > 1 {
-		command, err := cli.command(args[:2]...)
-		switch err := err.(type) {
-		case nil:
-			command("--help")
-			return nil
-		case initErr:
-			return err.error
-		}
-	}
 	if len(args) > 0 {
-		command, err := cli.command(args[0])
-		switch err := err.(type) {
-		case nil:
-			command("--help")
-			return nil
-		case initErr:
-			return err.error
+		command, depth, err := cli.command(args...)
+		if err == nil {
+			defer cli.beginDispatch(strings.Join(args[:depth], " "))()
+			return cli.chain(command)("--help")
 		}
 		cli.noSuchCommand(args[0])
 	}
@@ -154,6 +295,12 @@ func (cli *Cli) CmdHelp(args ...string) error {
 		cli.Usage()
 	}
 
+	if cli.Stderr == nil {
+		cli.Stderr = os.Stderr
+	}
+	fmt.Fprint(cli.Stderr, "\nEvery command also accepts --output/--format to select text, json, yaml, or a custom Go template (template=...) for its output.\n")
+	cli.pluginHelp(cli.Stderr)
+
 	return nil
 }
 
@@ -166,7 +313,15 @@ func (cli *Cli) CmdHelp(args ...string) error {
 // 一个子命令代表一个可以被Docker 命令行客户端完成的动作
 //
 // 要查看所有可用的子命令，运行 "docker --help".
-func Subcmd(name string, synopses []string, description string, exitOnError bool) *flag.FlagSet {
+//
+// Unless passed NoOutputFlag(), the returned FlagSet also accepts
+// --output/--format (text, json, yaml, or template=TEMPLATE); see Emit.
+func Subcmd(name string, synopses []string, description string, exitOnError bool, opts ...SubcmdOption) *flag.FlagSet {
+	cfg := &subcmdConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	var errorHandling flag.ErrorHandling
 	if exitOnError {
 		errorHandling = flag.ExitOnError
@@ -174,6 +329,11 @@ func Subcmd(name string, synopses []string, description string, exitOnError bool
 		errorHandling = flag.ContinueOnError
 	}
 	flags := flag.NewFlagSet(name, errorHandling)
+
+	if !cfg.noOutputFlag {
+		flags.Var(outputValue{}, []string{"-output", "-format"}, "Output format: text, json, yaml, or template=TEMPLATE")
+	}
+
 	flags.Usage = func() {
 		flags.ShortUsage()
 		flags.PrintDefaults()
@@ -189,6 +349,14 @@ func Subcmd(name string, synopses []string, description string, exitOnError bool
 			synopses = []string{""}
 		}
 
+		// Prefer the full nested command path ("image ls") set by Run or
+		// CmdHelp while dispatching, falling back to the leaf name when
+		// Subcmd is built outside of a dispatch (e.g. in tests).
+		path := name
+		if currentCommandPath != "" {
+			path = currentCommandPath
+		}
+
 		// Allow for multiple command usage synopses.
 		for i, synopsis := range synopses {
 			lead := "\t"
@@ -201,12 +369,17 @@ func Subcmd(name string, synopses []string, description string, exitOnError bool
 				synopsis = " " + synopsis
 			}
 
-			fmt.Fprintf(flags.Out(), "\n%sdocker %s%s%s", lead, name, options, synopsis)
+			fmt.Fprintf(flags.Out(), "\n%sdocker %s%s%s", lead, path, options, synopsis)
 		}
 
 		fmt.Fprintf(flags.Out(), "\n\n%s\n", description)
 	}
 
+	if subcmdObserver != nil {
+		subcmdObserver(name, flags)
+		panic(completionDryRun{})
+	}
+
 	return flags
 }
 
@@ -0,0 +1,59 @@
+package cli
+
+import "testing"
+
+type lookupHandler struct{}
+
+func (lookupHandler) CmdImage(args ...string) error   { return nil }
+func (lookupHandler) CmdImageLs(args ...string) error { return nil }
+
+type lookupGrouper struct{}
+
+func (lookupGrouper) SubHandlers() map[string]Handler {
+	return map[string]Handler{"container": containerHandler{}}
+}
+
+type containerHandler struct{}
+
+func (containerHandler) CmdLs(args ...string) error { return nil }
+
+func TestLookupCommandLongestPrefixMatch(t *testing.T) {
+	handlers := []Handler{lookupHandler{}}
+
+	_, depth, err := lookupCommand(handlers, []string{"image", "ls", "extra"})
+	if err != nil {
+		t.Fatalf("lookupCommand: %v", err)
+	}
+	if depth != 2 {
+		t.Fatalf("expected the longer \"image ls\" match (depth 2), got depth %d", depth)
+	}
+
+	_, depth, err = lookupCommand(handlers, []string{"image", "unknown"})
+	if err != nil {
+		t.Fatalf("lookupCommand: %v", err)
+	}
+	if depth != 1 {
+		t.Fatalf("expected fallback to the shorter \"image\" match (depth 1), got depth %d", depth)
+	}
+}
+
+func TestLookupCommandRecursesIntoGrouper(t *testing.T) {
+	handlers := []Handler{lookupGrouper{}}
+
+	_, depth, err := lookupCommand(handlers, []string{"container", "ls"})
+	if err != nil {
+		t.Fatalf("lookupCommand: %v", err)
+	}
+	if depth != 2 {
+		t.Fatalf("expected \"container ls\" to consume 2 tokens via the Grouper, got depth %d", depth)
+	}
+}
+
+func TestLookupCommandNotFound(t *testing.T) {
+	handlers := []Handler{lookupHandler{}}
+
+	_, _, err := lookupCommand(handlers, []string{"nope"})
+	if err == nil {
+		t.Fatal("expected an error for an unregistered command")
+	}
+}
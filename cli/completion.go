@@ -0,0 +1,328 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	flag "github.com/docker/docker/pkg/mflag"
+)
+
+// CompletionProvider may optionally be implemented by a Handler to supply
+// dynamic completions for arguments a flag set can't describe on its own,
+// such as container or image IDs. GenerateCompletion wires this in by
+// emitting a call back into the "docker complete args" hidden command (see
+// CmdCompleteArgs), since the candidates can only be known at completion
+// time, not when the script is generated. The hidden command is invoked as
+// two tokens, "complete" and "args", rather than "__complete", so that
+// Cli.command's per-token camel-casing resolves it to CmdCompleteArgs
+// instead of falling through to noSuchCommand.
+// CompletionProvider接口 可以被一个Handler作为可选实现，
+// 用于为flag set无法描述的参数（例如容器或镜像ID）提供动态补全
+// GenerateCompletion通过生成一段回调隐藏命令"docker complete args"
+// （参见CmdCompleteArgs）的脚本来接入它，因为这些候选项只能在
+// 实际补全时才能确定，在生成脚本时是无法得知的。这个隐藏命令被拆成
+// "complete"和"args"两个token调用，而不是"__complete"，这样
+// Cli.command对每个token分别做驼峰化处理后才能解析到CmdCompleteArgs，
+// 否则会落空到noSuchCommand
+type CompletionProvider interface {
+	// Complete returns the candidate completions for the word currently
+	// being typed for the given subcommand and the arguments preceding it.
+	Complete(cmd string, args []string, current string) []string
+}
+
+// CompletionSafe must be implemented by a Handler before any of its Cmd
+// methods are considered by GenerateCompletion. Implementing it is the
+// handler's promise that every one of its CmdFoo methods calls Subcmd as
+// its first statement, with no side effects before that point, so it is
+// safe to invoke in dry-run mode purely to recover the *flag.FlagSet it
+// builds. Handlers that don't implement this marker are skipped entirely
+// -- GenerateCompletion never blindly runs arbitrary handler code just
+// because a user typed "docker completion bash".
+// CompletionSafe接口 必须被一个Handler实现，它的Cmd方法才会被
+// GenerateCompletion考虑。实现它相当于handler做出承诺：它的每一个
+// CmdFoo方法都会把Subcmd作为第一条语句调用，并且在此之前没有任何副作用，
+// 因此可以安全地以dry-run模式调用它，仅仅为了取回它构建的*flag.FlagSet
+// 没有实现这个标记接口的handler会被完全跳过——GenerateCompletion绝不会
+// 仅仅因为用户输入了"docker completion bash"就盲目运行任意的handler代码
+type CompletionSafe interface {
+	CompletionSafe()
+}
+
+// commandFlags pairs a discovered Cmd method with the *flag.FlagSet it
+// builds (captured by running it once in dry-run mode) and the Handler
+// it came from, so GenerateCompletion can consult CompletionProvider.
+type commandFlags struct {
+	name    string
+	flags   *flag.FlagSet
+	handler Handler
+}
+
+// subcmdObserver, when non-nil, is invoked by Subcmd with every FlagSet it
+// creates, then aborts the calling CmdFoo via panic before it parses any
+// arguments or runs its business logic. discoverCommandFlags is the only
+// caller that sets it, to introspect a command's flags without running it.
+var subcmdObserver func(name string, flags *flag.FlagSet)
+
+// completionDryRun is the panic value Subcmd raises once subcmdObserver
+// has captured a FlagSet, so captureFlags can recover it and move on.
+type completionDryRun struct{}
+
+// discoverCommandFlags walks cli.handlers the same way cli.command does,
+// but only considers handlers that opt in by implementing CompletionSafe.
+// For each one it runs every CmdFoo method in dry-run mode so the
+// *flag.FlagSet each builds via Subcmd can be introspected.
+func (cli *Cli) discoverCommandFlags() []commandFlags {
+	var commands []commandFlags
+	for _, h := range cli.handlers {
+		if h == nil {
+			continue
+		}
+		if _, ok := h.(CompletionSafe); !ok {
+			continue
+		}
+		t := reflect.TypeOf(h)
+		for i := 0; i < t.NumMethod(); i++ {
+			m := t.Method(i)
+			if !strings.HasPrefix(m.Name, "Cmd") || m.Name == "CmdHelp" {
+				continue
+			}
+			fn, ok := reflect.ValueOf(h).MethodByName(m.Name).Interface().(func(...string) error)
+			if !ok {
+				continue
+			}
+			name := strings.ToLower(strings.TrimPrefix(m.Name, "Cmd"))
+			commands = append(commands, commandFlags{name: name, flags: captureFlags(fn), handler: h})
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool { return commands[i].name < commands[j].name })
+	return commands
+}
+
+// captureFlags runs fn in dry-run mode: fn is expected to build its
+// *flag.FlagSet via Subcmd before doing anything else, so subcmdObserver
+// can record it and unwind via panic/recover before fn parses arguments
+// or has any side effects. Returns nil if fn never called Subcmd. Any
+// recovered value other than completionDryRun is a genuine bug in fn and
+// is re-panicked rather than swallowed.
+func captureFlags(fn func(...string) error) (flags *flag.FlagSet) {
+	subcmdObserver = func(_ string, fs *flag.FlagSet) { flags = fs }
+	defer func() {
+		subcmdObserver = nil
+		if r := recover(); r != nil {
+			if _, ok := r.(completionDryRun); !ok {
+				panic(r)
+			}
+		}
+	}()
+	fn()
+	return
+}
+
+// GenerateCompletion writes a completion script for the given shell
+// ("bash", "zsh", "fish" or "powershell") to w, covering every subcommand
+// discovered on cli.handlers, their flags, and any dynamic arguments a
+// Handler offers through CompletionProvider. Embedding the cli package
+// gives a daemon or plugin completions for free.
+// GenerateCompletion 为指定的shell（"bash"、"zsh"、"fish"或"powershell"）
+// 向w写入补全脚本，覆盖了在cli.handlers中发现的所有子命令、它们的标记，
+// 以及Handler通过CompletionProvider提供的任何动态参数。
+// 嵌入cli包的守护进程或插件可以免费获得补全功能
+func (cli *Cli) GenerateCompletion(shell string, w io.Writer) error {
+	commands := cli.discoverCommandFlags()
+
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	switch shell {
+	case "bash":
+		return generateBashCompletion(bw, commands)
+	case "zsh":
+		return generateZshCompletion(bw, commands)
+	case "fish":
+		return generateFishCompletion(bw, commands)
+	case "powershell":
+		return generatePowershellCompletion(bw, commands)
+	default:
+		return fmt.Errorf("unsupported shell %q: must be one of bash, zsh, fish, powershell", shell)
+	}
+}
+
+func commandNames(commands []commandFlags) []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return names
+}
+
+func flagNames(flags *flag.FlagSet) []string {
+	if flags == nil {
+		return nil
+	}
+	var names []string
+	flags.VisitAll(func(f *flag.Flag) {
+		for _, name := range strings.Split(f.Name, ",") {
+			names = append(names, "--"+strings.TrimSpace(name))
+		}
+	})
+	return names
+}
+
+func generateBashCompletion(w io.Writer, commands []commandFlags) error {
+	fmt.Fprintf(w, "# bash completion for docker\n_docker() {\n")
+	fmt.Fprintf(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} cmd=${COMP_WORDS[1]}\n")
+	fmt.Fprintf(w, "\tif [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", strings.Join(commandNames(commands), " "))
+	fmt.Fprintf(w, "\t\treturn\n\tfi\n\tcase \"$cmd\" in\n")
+	for _, c := range commands {
+		words := strings.Join(flagNames(c.flags), " ")
+		fmt.Fprintf(w, "\t%s)\n", c.name)
+		if _, ok := c.handler.(CompletionProvider); ok {
+			// Dynamic candidates (container/image IDs, etc.) can only be
+			// resolved at completion time, so shell out to the same
+			// docker binary's hidden "complete args" command, which calls
+			// the handler's CompletionProvider.Complete for us.
+			fmt.Fprintf(w, "\t\tlocal dyn=$(docker complete args %s \"$cur\" \"${COMP_WORDS[@]:2:$COMP_CWORD-2}\")\n", c.name)
+			fmt.Fprintf(w, "\t\tCOMPREPLY=( $(compgen -W \"%s $dyn\" -- \"$cur\") )\n", words)
+		} else {
+			fmt.Fprintf(w, "\t\tCOMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )\n", words)
+		}
+		fmt.Fprintf(w, "\t\t;;\n")
+	}
+	fmt.Fprintf(w, "\tesac\n}\ncomplete -F _docker docker\n")
+	return nil
+}
+
+func generateZshCompletion(w io.Writer, commands []commandFlags) error {
+	fmt.Fprintf(w, "#compdef docker\n_docker() {\n\tlocal -a subcmds\n\tsubcmds=(\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t\t%q\n", c.name)
+	}
+	fmt.Fprintf(w, "\t)\n\n\tif (( CURRENT == 2 )); then\n\t\t_describe 'command' subcmds\n\t\treturn\n\tfi\n\n")
+	fmt.Fprintf(w, "\tlocal cmd=${words[2]}\n\tcase \"$cmd\" in\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t\t%s)\n", c.name)
+		fmt.Fprintf(w, "\t\t\tlocal -a flags\n\t\t\tflags=(%s)\n", strings.Join(flagNames(c.flags), " "))
+		if _, ok := c.handler.(CompletionProvider); ok {
+			fmt.Fprintf(w, "\t\t\tlocal -a dyn\n\t\t\tdyn=(${(f)\"$(docker complete args %s \"$words[CURRENT]\" \"${words[@]:2:$((CURRENT-3))}\")\"})\n", c.name)
+			fmt.Fprintf(w, "\t\t\tcompadd -a flags dyn\n")
+		} else {
+			fmt.Fprintf(w, "\t\t\tcompadd -a flags\n")
+		}
+		fmt.Fprintf(w, "\t\t\t;;\n")
+	}
+	fmt.Fprintf(w, "\tesac\n}\n_docker\n")
+	return nil
+}
+
+func generateFishCompletion(w io.Writer, commands []commandFlags) error {
+	for _, c := range commands {
+		fmt.Fprintf(w, "complete -c docker -n '__fish_use_subcommand' -a %s\n", c.name)
+		for _, f := range flagNames(c.flags) {
+			fmt.Fprintf(w, "complete -c docker -n '__fish_seen_subcommand_from %s' -l %s\n", c.name, strings.TrimPrefix(f, "--"))
+		}
+		if _, ok := c.handler.(CompletionProvider); ok {
+			fmt.Fprintf(w, "complete -c docker -n '__fish_seen_subcommand_from %s' -f -a '(docker complete args %s (commandline -ct))'\n", c.name, c.name)
+		}
+	}
+	return nil
+}
+
+func generatePowershellCompletion(w io.Writer, commands []commandFlags) error {
+	fmt.Fprintf(w, "Register-ArgumentCompleter -Native -CommandName docker -ScriptBlock {\n")
+	fmt.Fprintf(w, "\tparam($wordToComplete, $commandAst, $cursorPosition)\n")
+	fmt.Fprintf(w, "\t$tokens = $commandAst.CommandElements | ForEach-Object { $_.ToString() }\n")
+	fmt.Fprintf(w, "\tif ($tokens.Count -le 2) {\n\t\t@(\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t\t\t%q\n", c.name)
+	}
+	fmt.Fprintf(w, "\t\t) | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object { [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_) }\n\t\treturn\n\t}\n")
+	fmt.Fprintf(w, "\t$cmd = $tokens[1]\n\tswitch ($cmd) {\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "\t\t%q {\n", c.name)
+		fmt.Fprintf(w, "\t\t\t$candidates = @(%s)\n", quotePowershellList(flagNames(c.flags)))
+		if _, ok := c.handler.(CompletionProvider); ok {
+			fmt.Fprintf(w, "\t\t\t$candidates += (docker complete args %s $wordToComplete) -split \"`n\" | Where-Object { $_ -ne '' }\n", c.name)
+		}
+		fmt.Fprintf(w, "\t\t\t$candidates | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object { [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_) }\n")
+		fmt.Fprintf(w, "\t\t}\n")
+	}
+	fmt.Fprintf(w, "\t}\n}\n")
+	return nil
+}
+
+// quotePowershellList renders names as a comma-separated list of
+// single-quoted Powershell string literals, e.g. 'a', 'b'.
+func quotePowershellList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, n := range names {
+		quoted[i] = "'" + n + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// CmdCompletion generates a shell completion script for the docker CLI.
+//
+// Usage: docker completion SHELL
+// CmdCompletion 为docker命令行生成shell补全脚本
+//
+// 用法：docker completion SHELL
+func (cli *Cli) CmdCompletion(args ...string) error {
+	cmd := Subcmd("completion", []string{"SHELL"}, "Generate shell completion scripts", true)
+	if err := cmd.Parse(args); err != nil {
+		return err
+	}
+	if cmd.NArg() != 1 {
+		cmd.Usage()
+		return nil
+	}
+	return cli.GenerateCompletion(cmd.Arg(0), os.Stdout)
+}
+
+// CmdCompleteArgs is the hidden command the scripts GenerateCompletion
+// emits shell out to in order to resolve dynamic arguments through
+// whichever Handler implements CompletionProvider for the given
+// subcommand. It prints one completion candidate per line. It is invoked
+// as "complete args" (two tokens) rather than "__complete" so that
+// Cli.command's per-token camel-casing resolves to this method.
+//
+// Usage: docker complete args CMD CURRENT [ARG...]
+// CmdCompleteArgs 是GenerateCompletion生成的脚本回调的隐藏命令，
+// 用来通过实现了CompletionProvider的Handler解析指定子命令的动态参数。
+// 它会逐行打印每一个补全候选项。它被调用时拆成"complete"和"args"两个
+// token，而不是"__complete"，这样Cli.command对每个token做驼峰化处理
+// 之后才能解析到这个方法
+//
+// 用法：docker complete args CMD CURRENT [ARG...]
+func (cli *Cli) CmdCompleteArgs(args ...string) error {
+	if len(args) < 2 {
+		return nil
+	}
+	name, current, rest := args[0], args[1], args[2:]
+
+	for _, c := range cli.discoverCommandFlags() {
+		if c.name != name {
+			continue
+		}
+		provider, ok := c.handler.(CompletionProvider)
+		if !ok {
+			return nil
+		}
+		for _, candidate := range provider.Complete(name, rest, current) {
+			fmt.Fprintln(os.Stdout, candidate)
+		}
+		return nil
+	}
+	return nil
+}
+
+// CompletionSafe marks the built-in Cli handler as safe for completion
+// discovery: both CmdCompletion and CmdCompleteArgs either call Subcmd
+// as their first statement or are no-ops for the zero-argument call
+// discoverCommandFlags makes.
+func (cli *Cli) CompletionSafe() {}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeCompletionHandler is a CompletionSafe handler with one CmdFoo method
+// (which calls Subcmd first, as CompletionSafe promises) and a
+// CompletionProvider implementation, used to exercise discoverCommandFlags
+// and the generators without depending on the real docker handlers.
+type fakeCompletionHandler struct{}
+
+func (fakeCompletionHandler) CompletionSafe() {}
+
+func (fakeCompletionHandler) CmdFoo(args ...string) error {
+	cmd := Subcmd("foo", nil, "fake command", false)
+	cmd.Var(outputValue{}, []string{"-bar"}, "a fake flag")
+	return cmd.Parse(args)
+}
+
+func (fakeCompletionHandler) Complete(cmd string, args []string, current string) []string {
+	return []string{"candidate"}
+}
+
+// fakeUnsafeHandler does not implement CompletionSafe and should never be
+// introspected by discoverCommandFlags.
+type fakeUnsafeHandler struct{}
+
+func (fakeUnsafeHandler) CmdBar(args ...string) error {
+	panic("fakeUnsafeHandler.CmdBar must never run during completion discovery")
+}
+
+func TestDiscoverCommandFlagsSkipsHandlersWithoutCompletionSafe(t *testing.T) {
+	cli := &Cli{handlers: []Handler{fakeCompletionHandler{}, fakeUnsafeHandler{}}}
+
+	commands := cli.discoverCommandFlags()
+
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+	if len(names) != 1 || names[0] != "foo" {
+		t.Fatalf("expected only [foo], got %v", names)
+	}
+}
+
+func TestCaptureFlagsRepanicsOnUnexpectedPanic(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r != "boom" {
+			t.Fatalf("expected captureFlags to re-panic with %q, got %v", "boom", r)
+		}
+	}()
+
+	captureFlags(func(...string) error {
+		panic("boom")
+	})
+}
+
+func TestGenerateBashCompletionCallsCompleteArgsForProvider(t *testing.T) {
+	commands := []commandFlags{
+		{name: "foo", handler: fakeCompletionHandler{}},
+	}
+
+	var buf strings.Builder
+	if err := generateBashCompletion(&buf, commands); err != nil {
+		t.Fatalf("generateBashCompletion: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, "docker complete args foo") {
+		t.Fatalf("expected dynamic completion to shell out via \"docker complete args foo\", got:\n%s", out)
+	}
+	if strings.Contains(out, "__complete") {
+		t.Fatalf("must not reference the unreachable \"__complete\" token, got:\n%s", out)
+	}
+}
+
+// TestCompleteArgsTokensResolveToCmdCompleteArgs is a regression test for
+// the bug where the generators invoked "docker __complete ...": a single
+// token "__complete" camel-cases to "Cmd__complete", which never matches
+// CmdCompleteArgs. "complete", "args" must resolve to it.
+func TestCompleteArgsTokensResolveToCmdCompleteArgs(t *testing.T) {
+	cli := New()
+
+	_, depth, err := cli.command("complete", "args", "foo", "f")
+	if err != nil {
+		t.Fatalf("cli.command(\"complete\", \"args\", ...): %v", err)
+	}
+	if depth != 2 {
+		t.Fatalf("expected \"complete args\" to consume 2 tokens, consumed %d", depth)
+	}
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Supported values for the --output/--format flag Subcmd injects into
+// every FlagSet it builds unless passed NoOutputFlag(). "template=..."
+// accepts a Go text/template body.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// OutputFormatter renders a value produced by a command in a specific,
+// scriptable format. Emit looks one up by resolving the --output/--format
+// flag a Subcmd-built FlagSet carries unless built with NoOutputFlag().
+// OutputFormatter接口 以特定的、可用于脚本的格式渲染一个命令产生的值
+// Emit 通过解析每一个由Subcmd构建的FlagSet所携带的--output/--format标记
+// 来查找对应的formatter
+type OutputFormatter interface {
+	Format(w io.Writer, v interface{}) error
+}
+
+// PrettyPrinter renders v as human-readable text for the "text" output
+// format. It returns handled=false for types it doesn't know how to
+// render, so textFormatter falls through to the next registered printer
+// and, ultimately, a generic rendering.
+type PrettyPrinter func(w io.Writer, v interface{}) (handled bool, err error)
+
+// prettyPrinters are tried, in registration order, by the "text" format.
+var prettyPrinters []PrettyPrinter
+
+// RegisterPrettyPrinter adds a PrettyPrinter consulted by the "text"
+// output format before it falls back to a generic "%v" rendering. A
+// Handler calls this, typically from an init, to give its own result
+// types a readable representation without reimplementing
+// --output=json/yaml support itself.
+// RegisterPrettyPrinter 添加一个PrettyPrinter，在"text"输出格式回退到通用的
+// "%v"渲染之前会先查询它。一个Handler（通常在init中）调用它，
+// 为自己的结果类型提供可读的展现形式，而无需自己重新实现--output=json/yaml支持
+func RegisterPrettyPrinter(p PrettyPrinter) {
+	prettyPrinters = append(prettyPrinters, p)
+}
+
+type textFormatter struct{}
+
+func (textFormatter) Format(w io.Writer, v interface{}) error {
+	for _, p := range prettyPrinters {
+		handled, err := p(w, v)
+		if err != nil {
+			return err
+		}
+		if handled {
+			return nil
+		}
+	}
+	fmt.Fprintf(w, "%v\n", v)
+	return nil
+}
+
+type jsonFormatter struct{}
+
+func (jsonFormatter) Format(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type yamlFormatter struct{}
+
+func (yamlFormatter) Format(w io.Writer, v interface{}) error {
+	out, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+type templateFormatter struct{ tmpl *template.Template }
+
+func (f templateFormatter) Format(w io.Writer, v interface{}) error {
+	return f.tmpl.Execute(w, v)
+}
+
+// parseFormat resolves an --output/--format value into an OutputFormatter.
+func parseFormat(output string) (OutputFormatter, error) {
+	switch {
+	case output == "" || output == FormatText:
+		return textFormatter{}, nil
+	case output == FormatJSON:
+		return jsonFormatter{}, nil
+	case output == FormatYAML:
+		return yamlFormatter{}, nil
+	case strings.HasPrefix(output, "template="):
+		tmpl, err := template.New("output").Parse(strings.TrimPrefix(output, "template="))
+		if err != nil {
+			return nil, fmt.Errorf("invalid --output template: %v", err)
+		}
+		return templateFormatter{tmpl}, nil
+	default:
+		return nil, fmt.Errorf("unsupported --output %q: must be one of %s, %s, %s or template=TEMPLATE", output, FormatText, FormatJSON, FormatYAML)
+	}
+}
+
+// dispatchingCli is the *Cli currently running a command, set by Run and
+// CmdHelp for the duration of a single dispatch the same way
+// currentCommandPath is. The selected OutputFormatter itself lives on
+// that Cli (its formatter field), not here, so --output/--format state
+// never leaks into another Cli or into the next, unrelated Run call:
+// Run/CmdHelp reset formatter to Cli.Formatter-or-text before every
+// dispatch, and outputValue.Set/Emit always go through dispatchingCli.
+var dispatchingCli *Cli
+
+// outputValue implements flag.Value so Set runs as soon as
+// --output/--format is parsed, installing the formatter on the
+// dispatching Cli immediately rather than requiring a second pass over
+// the flags after Parse.
+type outputValue struct{}
+
+func (outputValue) String() string { return "" }
+
+func (outputValue) Set(s string) error {
+	f, err := parseFormat(s)
+	if err != nil {
+		return err
+	}
+	if dispatchingCli != nil {
+		dispatchingCli.formatter = f
+	}
+	return nil
+}
+
+// Emit renders v through the format selected by --output/--format (text
+// by default) and writes it to stdout. Handlers call this instead of
+// writing results directly, so every Docker subcommand gets consistent,
+// scriptable output for free.
+// Emit 通过--output/--format选择的格式（默认为text）渲染v，并将其写入标准输出
+// Handler调用此方法而不是直接写入结果，这样每一个Docker子命令都能免费获得
+// 一致的、可用于脚本的输出
+func Emit(v interface{}) error {
+	f := OutputFormatter(textFormatter{})
+	if dispatchingCli != nil && dispatchingCli.formatter != nil {
+		f = dispatchingCli.formatter
+	}
+	return f.Format(os.Stdout, v)
+}
+
+// subcmdConfig holds the options Subcmd applies when building a FlagSet.
+type subcmdConfig struct {
+	noOutputFlag bool
+}
+
+// SubcmdOption configures optional Subcmd behavior.
+type SubcmdOption func(*subcmdConfig)
+
+// NoOutputFlag opts a Subcmd out of the auto-injected --output/--format
+// flag, for commands that don't produce a value worth formatting, such
+// as ones that just stream logs.
+// NoOutputFlag 让一个Subcmd不自动注入--output/--format标记，
+// 适用于那些不产生需要格式化的值的命令，例如只是持续输出日志的命令
+func NoOutputFlag() SubcmdOption {
+	return func(c *subcmdConfig) { c.noOutputFlag = true }
+}
@@ -0,0 +1,45 @@
+package cli
+
+import "testing"
+
+func TestParseFormat(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    OutputFormatter
+		wantErr bool
+	}{
+		{input: "", want: textFormatter{}},
+		{input: FormatText, want: textFormatter{}},
+		{input: FormatJSON, want: jsonFormatter{}},
+		{input: FormatYAML, want: yamlFormatter{}},
+		{input: "bogus", wantErr: true},
+		{input: "template=invalid{{", wantErr: true},
+	}
+
+	for _, c := range cases {
+		got, err := parseFormat(c.input)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseFormat(%q): expected error, got none", c.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseFormat(%q): unexpected error: %v", c.input, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseFormat(%q) = %#v, want %#v", c.input, got, c.want)
+		}
+	}
+}
+
+func TestParseFormatTemplate(t *testing.T) {
+	f, err := parseFormat("template={{.Name}}")
+	if err != nil {
+		t.Fatalf("parseFormat(template=...): %v", err)
+	}
+	if _, ok := f.(templateFormatter); !ok {
+		t.Fatalf("expected templateFormatter, got %T", f)
+	}
+}
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// errNoSuchPlugin is returned by runPlugin when asked to run a name that
+// was not registered by DiscoverPlugins.
+var errNoSuchPlugin = errors.New("no such plugin")
+
+// pluginMetadataTimeout bounds how long DiscoverPlugins waits for a single
+// "docker-<name>" executable to answer pluginMetadataCommand. Every file
+// on $PATH matching that pattern gets probed, not just ones in a
+// dedicated plugins directory, so a hung or unrelated script must not be
+// able to block "docker help"/"docker --help" indefinitely.
+const pluginMetadataTimeout = 2 * time.Second
+
+// pluginMetadataWaitDelay bounds how long fetchPluginMetadata waits for a
+// plugin's process (and any child it left holding stdout open) to
+// actually exit and close its pipes once pluginMetadataTimeout has
+// expired, before giving up on reading its output.
+const pluginMetadataWaitDelay = 1 * time.Second
+
+// pluginMetadataCommand is the argument a plugin must respond to with a
+// JSON-encoded pluginMetadata on stdout.
+const pluginMetadataCommand = "docker-cli-plugin-metadata"
+
+// Plugin represents an external "docker-<name>" executable discovered on
+// one of Cli.PluginPaths. Once registered it behaves like any other
+// subcommand: it is listed by CmdHelp and dispatched to by Cli.Run.
+// Plugin 代表在Cli.PluginPaths中的某一路径下发现的外部可执行文件"docker-<name>"
+// 一旦注册，它的行为就像其他子命令一样：会被CmdHelp列出，并被Cli.Run调用
+type Plugin struct {
+	Name             string
+	Path             string
+	ShortDescription string
+	Vendor           string
+	Version          string
+}
+
+// pluginMetadata is the JSON document a plugin prints in response to
+// being invoked with the pluginMetadataCommand argument.
+type pluginMetadata struct {
+	Name             string `json:"name"`
+	ShortDescription string `json:"short_description"`
+	Vendor           string `json:"vendor"`
+	Version          string `json:"version"`
+}
+
+// defaultPluginPaths returns the directories DiscoverPlugins searches when
+// Cli.PluginPaths has not been set explicitly: $PATH followed by
+// ~/.docker/cli-plugins.
+func defaultPluginPaths() []string {
+	paths := filepath.SplitList(os.Getenv("PATH"))
+	if home := os.Getenv("HOME"); home != "" {
+		paths = append(paths, filepath.Join(home, ".docker", "cli-plugins"))
+	}
+	return paths
+}
+
+// DiscoverPlugins scans cli.PluginPaths (defaulting to defaultPluginPaths
+// if unset) for executables named "docker-<name>" and registers each one
+// it finds as a plugin. Every discovered plugin is queried for its
+// metadata so it can be listed alongside the built-in commands.
+// DiscoverPlugins扫描cli.PluginPaths（未设置时默认为defaultPluginPaths），
+// 查找名为"docker-<name>"的可执行文件，并将找到的每一个都注册为插件。
+// 每一个被发现的插件都会被查询其元数据，以便和内建命令一起被列出
+func (cli *Cli) DiscoverPlugins() {
+	if cli.PluginPaths == nil {
+		cli.PluginPaths = defaultPluginPaths()
+	}
+
+	seen := make(map[string]bool)
+	plugins := make(map[string]*Plugin)
+	for _, dir := range cli.PluginPaths {
+		if dir == "" {
+			continue
+		}
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasPrefix(name, "docker-") {
+				continue
+			}
+			subcommand := strings.TrimPrefix(name, "docker-")
+			if subcommand == "" || seen[subcommand] {
+				continue
+			}
+			if entry.Mode()&0111 == 0 {
+				continue
+			}
+			seen[subcommand] = true
+
+			path := filepath.Join(dir, name)
+			plugin := &Plugin{Name: subcommand, Path: path}
+			if meta, err := fetchPluginMetadata(path); err == nil {
+				plugin.ShortDescription = meta.ShortDescription
+				plugin.Vendor = meta.Vendor
+				plugin.Version = meta.Version
+			}
+			plugins[subcommand] = plugin
+		}
+	}
+
+	cli.plugins = plugins
+}
+
+// fetchPluginMetadata invokes the plugin at path with the
+// pluginMetadataCommand argument and parses its JSON response, killing
+// the process if it hasn't answered within pluginMetadataTimeout.
+func fetchPluginMetadata(path string) (*pluginMetadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginMetadataTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path, pluginMetadataCommand)
+	// Killing the plugin process on timeout isn't enough by itself: if it
+	// spawned a child that inherited stdout (a shell script backgrounding
+	// or exec'ing into something else), Output's pipe stays open and the
+	// read blocks on that child long after the plugin itself is dead.
+	// WaitDelay bounds how long we wait for everything to actually close
+	// its end of the pipe once the context expires.
+	cmd.WaitDelay = pluginMetadataWaitDelay
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	meta := &pluginMetadata{}
+	if err := json.Unmarshal(out, meta); err != nil {
+		return nil, err
+	}
+	return meta, nil
+}
+
+// runPlugin execs the plugin registered under name, wiring stdin, stdout
+// and stderr through to the current process and translating a non-zero
+// exit code into a StatusError so the caller can propagate it.
+func (cli *Cli) runPlugin(name string, args ...string) error {
+	plugin, ok := cli.plugins[name]
+	if !ok {
+		return errNoSuchPlugin
+	}
+
+	cmd := exec.Command(plugin.Path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return StatusError{Status: exitErr.Error(), StatusCode: exitErr.Sys().(interface {
+				ExitStatus() int
+			}).ExitStatus()}
+		}
+		return err
+	}
+	return nil
+}
+
+// pluginHelp writes the "Plugins" section listed by CmdHelp, one line per
+// discovered plugin, sorted by name.
+func (cli *Cli) pluginHelp(w io.Writer) {
+	if len(cli.plugins) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(cli.plugins))
+	for name := range cli.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprint(w, "\nPlugins:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "    %-16s%s\n", name, cli.plugins[name].ShortDescription)
+	}
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeScript writes an executable shell script to dir/name and returns its
+// path.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755); err != nil {
+		t.Fatalf("writing script: %v", err)
+	}
+	return path
+}
+
+func TestFetchPluginMetadataTimesOutOnHungPlugin(t *testing.T) {
+	dir := t.TempDir()
+	path := writeScript(t, dir, "docker-hung", "sleep 60\n")
+
+	start := time.Now()
+	_, err := fetchPluginMetadata(path)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected fetchPluginMetadata to return an error for a hung plugin")
+	}
+	if elapsed >= 60*time.Second {
+		t.Fatalf("fetchPluginMetadata did not respect pluginMetadataTimeout, took %v", elapsed)
+	}
+}
+
+func TestFetchPluginMetadataParsesResponse(t *testing.T) {
+	dir := t.TempDir()
+	path := writeScript(t, dir, "docker-ok", `echo '{"name":"ok","short_description":"a plugin"}'`+"\n")
+
+	meta, err := fetchPluginMetadata(path)
+	if err != nil {
+		t.Fatalf("fetchPluginMetadata: %v", err)
+	}
+	if meta.Name != "ok" || meta.ShortDescription != "a plugin" {
+		t.Fatalf("unexpected metadata: %+v", meta)
+	}
+}